@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+// lookupAppxPackage is a no-op outside Windows; Appx packages only exist
+// there.
+func lookupAppxPackage() (fullName, installPath string) {
+	return "", ""
+}
@@ -0,0 +1,53 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// appxRepositoryKey is the per-user app model repository Windows keeps for
+// every installed Appx package. Unlike the HKLM package repository (which
+// needs elevation to read) and %PROGRAMFILES%\WindowsApps (whose ACL denies
+// listing to ordinary processes), this key is readable by the owning user
+// without elevation.
+const appxRepositoryKey = `Software\Microsoft\Windows\CurrentVersion\AppModel\Repository\Packages`
+
+// lookupAppxPackage resolves the Spotify Appx package's full package name
+// (e.g. "SpotifyAB.SpotifyMusic_1.205.0.0_x86__zpdnekdrzrea0") and its
+// install location by walking the app model repository, so callers don't
+// have to rely on listing %PROGRAMFILES%\WindowsApps.
+func lookupAppxPackage() (fullName, installPath string) {
+	root, err := registry.OpenKey(registry.CURRENT_USER, appxRepositoryKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return "", ""
+	}
+	defer root.Close()
+
+	names, err := root.ReadSubKeyNames(-1)
+	if err != nil {
+		return "", ""
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "SpotifyAB.SpotifyMusic_") {
+			continue
+		}
+
+		pkgKey, err := registry.OpenKey(registry.CURRENT_USER, appxRepositoryKey+`\`+name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+
+		path, _, err := pkgKey.GetStringValue("Path")
+		pkgKey.Close()
+		if err == nil && len(path) != 0 {
+			return name, path
+		}
+	}
+
+	return "", ""
+}
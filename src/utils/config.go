@@ -1,23 +1,32 @@
 package utils
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/go-ini/ini"
+
+	"github.com/shh215/spicetify-cli/src/utils/spotifyapi"
 )
 
 var (
 	configLayout = map[string]map[string]string{
 		"Setting": map[string]string{
-			"spotify_path":   "",
-			"prefs_path":     "",
-			"current_theme":  "SpicetifyDefault",
-			"inject_css":     "1",
-			"replace_colors": "1",
+			"spotify_path":         "",
+			"prefs_path":           "",
+			"spotify_launcher":     "",
+			"spotify_install_type": "",
+			"current_theme":        "SpicetifyDefault",
+			"inject_css":           "1",
+			"replace_colors":       "1",
+			"schema_version":       strconv.Itoa(currentSchemaVersion),
 		},
 		"Preprocesses": map[string]string{
 			"disable_sentry":     "1",
@@ -38,11 +47,116 @@ var (
 			"extensions":                   "",
 			"custom_apps":                  "",
 		},
+		"Account": map[string]string{
+			"client_id":     "",
+			"redirect_port": "19847",
+			"scopes":        "playlist-read-private playlist-modify-private playlist-modify-public",
+		},
 	}
 )
 
+// currentSchemaVersion is bumped every time a migration is added to
+// migrations below.
+const currentSchemaVersion = 1
+
+// Migration moves a config.ini from one schema version to the next.
+// Apply should mutate cfg in place; it must be idempotent against a file
+// that is already on From, since ParseConfig only runs it once per bump.
+type Migration struct {
+	From, To int
+	Apply    func(*ini.File) error
+}
+
+// migrations must be kept sorted by From and run in order, each one
+// picking up where the previous left off.
+var migrations = []Migration{
+	{
+		From: 0,
+		To:   1,
+		Apply: func(cfg *ini.File) error {
+			setting, err := cfg.GetSection("Setting")
+			if err != nil {
+				return nil
+			}
+
+			preprocesses, err := cfg.GetSection("Preprocesses")
+			if err != nil {
+				if preprocesses, err = cfg.NewSection("Preprocesses"); err != nil {
+					return err
+				}
+			}
+
+			for keyName := range configLayout["Preprocesses"] {
+				key, err := setting.GetKey(keyName)
+				if err != nil {
+					continue
+				}
+
+				preprocesses.NewKey(keyName, key.Value())
+				setting.DeleteKey(keyName)
+			}
+
+			return nil
+		},
+	},
+}
+
+// schemaVersion reads the [Setting] schema_version key, treating a missing
+// section or key as version 0 (pre-dating the migration framework).
+func schemaVersion(cfg *ini.File) int {
+	section, err := cfg.GetSection("Setting")
+	if err != nil {
+		return 0
+	}
+
+	key, err := section.GetKey("schema_version")
+	if err != nil {
+		return 0
+	}
+
+	version, err := key.Int()
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+// applyMigrations walks migrations in order starting from cfg's current
+// schema_version, applying each one whose From matches, and reports
+// whether anything changed.
+func applyMigrations(cfg *ini.File) bool {
+	version := schemaVersion(cfg)
+	migrated := false
+
+	for _, migration := range migrations {
+		if migration.From != version {
+			continue
+		}
+
+		if err := migration.Apply(cfg); err != nil {
+			Fatal(err)
+		}
+
+		version = migration.To
+		migrated = true
+	}
+
+	if migrated {
+		section, err := cfg.GetSection("Setting")
+		if err != nil {
+			section, _ = cfg.NewSection("Setting")
+		}
+
+		setSectionValue(section, "schema_version", strconv.Itoa(version))
+	}
+
+	return migrated
+}
+
 type config struct {
 	path    string
+	profile string
 	content *ini.File
 }
 
@@ -51,11 +165,124 @@ type Config interface {
 	Write()
 	GetSection(string) *ini.Section
 	GetPath() string
+	SchemaVersion() int
+	GetAPIClient(ctx context.Context) (spotifyapi.SpotifyAPI, error)
+	Profile() string
 }
 
-// ParseConfig read config file content, return default config
-// if file doesn't exist.
+// defaultProfile is used whenever ParseConfigForProfile is called with an
+// empty profile name, and is what the legacy, pre-profile config.ini is
+// migrated into on first run.
+const defaultProfile = "default"
+
+// ParseConfig reads configPath verbatim, ignoring the profile directory
+// layout. It exists for callers that already resolved an explicit config
+// file location; ParseConfigForProfile is the profile-aware entry point.
 func ParseConfig(configPath string) Config {
+	return parseConfig(configPath, "")
+}
+
+// ParseConfigForProfile resolves the config.ini path for profile following
+// $SPICETIFY_CONFIG_HOME, then $XDG_CONFIG_HOME/spicetify, then
+// ~/.spicetify, migrating a legacy flat config.ini into the "default"
+// profile the first time it is called, and returns its Config.
+func ParseConfigForProfile(profile string) Config {
+	if len(profile) == 0 {
+		profile = defaultProfile
+	}
+
+	if err := validateProfileName(profile); err != nil {
+		Fatal(err)
+	}
+
+	configPath := profileConfigPath(profile)
+	migrateLegacyConfig(profile, configPath)
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		Fatal(err)
+	}
+
+	return parseConfig(configPath, profile)
+}
+
+// ListProfiles enumerates the profile directories under the resolved
+// config home, so the CLI can offer them, e.g. for `spicetify --profile`.
+func ListProfiles() []string {
+	entries, err := ioutil.ReadDir(profileHome())
+	if err != nil {
+		return nil
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+
+	return profiles
+}
+
+// profileHome is $SPICETIFY_CONFIG_HOME, $XDG_CONFIG_HOME/spicetify, or
+// ~/.spicetify, in that order of precedence.
+func profileHome() string {
+	if home := os.Getenv("SPICETIFY_CONFIG_HOME"); len(home) != 0 {
+		return home
+	}
+
+	if xdgHome := os.Getenv("XDG_CONFIG_HOME"); len(xdgHome) != 0 {
+		return filepath.Join(xdgHome, "spicetify")
+	}
+
+	return filepath.Join(os.Getenv("HOME"), ".spicetify")
+}
+
+func profileConfigPath(profile string) string {
+	return filepath.Join(profileHome(), profile, "config.ini")
+}
+
+// validateProfileName rejects a profile name that would escape profileHome
+// once joined into a path, e.g. "." , ".." or anything containing a path
+// separator.
+func validateProfileName(profile string) error {
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return fmt.Errorf("utils: invalid profile name %q", profile)
+	}
+
+	return nil
+}
+
+// migrateLegacyConfig moves a pre-profile config.ini (directly under
+// profileHome, with no profile subdirectory) into the "default" profile
+// the first time ParseConfigForProfile runs against it.
+func migrateLegacyConfig(profile, configPath string) {
+	if profile != defaultProfile {
+		return
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		return
+	}
+
+	legacyPath := filepath.Join(profileHome(), "config.ini")
+	content, err := ioutil.ReadFile(legacyPath)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(configPath, content, 0644); err != nil {
+		Fatal(err)
+	}
+
+	os.Remove(legacyPath)
+	PrintSuccess(`Migrated legacy config.ini into "default" profile.`)
+}
+
+func parseConfig(configPath, profile string) Config {
 	cfg, err := ini.LoadSources(
 		ini.LoadOptions{
 			IgnoreContinuation: true,
@@ -65,6 +292,7 @@ func ParseConfig(configPath string) Config {
 	if err != nil {
 		defaultConfig := config{
 			path:    configPath,
+			profile: profile,
 			content: getDefaultConfig(),
 		}
 		defaultConfig.Write()
@@ -73,6 +301,20 @@ func ParseConfig(configPath string) Config {
 	}
 
 	needRewrite := false
+
+	if schemaVersion(cfg) < currentSchemaVersion {
+		if original, err := ioutil.ReadFile(configPath); err == nil {
+			if err := ioutil.WriteFile(configPath+".bak", original, 0644); err != nil {
+				Fatal(err)
+			}
+		}
+
+		if applyMigrations(cfg) {
+			PrintSuccess("Config migrated to schema version " + strconv.Itoa(currentSchemaVersion) + ", old file backed up as config.ini.bak")
+			needRewrite = true
+		}
+	}
+
 	for sectionName, keyList := range configLayout {
 		section, err := cfg.GetSection(sectionName)
 		if err != nil {
@@ -87,6 +329,10 @@ func ParseConfig(configPath string) Config {
 		}
 	}
 
+	if redetectInstallMetadata(cfg) {
+		needRewrite = true
+	}
+
 	if needRewrite {
 		PrintSuccess("Config is updated.")
 		cfg.SaveTo(configPath)
@@ -94,6 +340,7 @@ func ParseConfig(configPath string) Config {
 
 	return config{
 		path:    configPath,
+		profile: profile,
 		content: cfg,
 	}
 }
@@ -117,11 +364,127 @@ func (c config) GetPath() string {
 	return c.path
 }
 
+// Profile returns the name of the profile this config was loaded for, or
+// "" if it was loaded from an explicit path via ParseConfig.
+func (c config) Profile() string {
+	return c.profile
+}
+
+// SchemaVersion returns the config's current [Setting] schema_version,
+// so callers can gate feature availability on it.
+func (c config) SchemaVersion() int {
+	return schemaVersion(c.content)
+}
+
+// GetAPIClient builds a Spotify Web API client from the [Account] section,
+// running the PKCE authorization flow (and opening the user's browser) the
+// first time it is called, then reusing the cached token on every call
+// after that.
+func (c config) GetAPIClient(ctx context.Context) (spotifyapi.SpotifyAPI, error) {
+	section := c.GetSection("Account")
+
+	clientID, err := section.GetKey("client_id")
+	if err != nil || len(clientID.Value()) == 0 {
+		return nil, fmt.Errorf(`utils: "client_id" is not set in [Account] section of %s`, c.path)
+	}
+
+	redirectPort, err := section.GetKey("redirect_port")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := redirectPort.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	scopesKey, err := section.GetKey("scopes")
+	if err != nil {
+		return nil, err
+	}
+
+	return spotifyapi.NewClient(ctx, spotifyapi.Config{
+		ClientID:     clientID.Value(),
+		RedirectPort: port,
+		Scopes:       strings.Fields(scopesKey.Value()),
+		ConfigDir:    filepath.Dir(c.path),
+	})
+}
+
+// detectedPaths caches FindAppPath/FindPrefFilePath results for the
+// lifetime of the process, so generating a default config for a second or
+// third profile doesn't re-run OS-level Spotify detection.
+var detectedPaths struct {
+	done          bool
+	spotifyPath   string
+	prefsFilePath string
+}
+
+func detectSpotifyPaths() (string, string) {
+	if !detectedPaths.done {
+		detectedPaths.spotifyPath = FindAppPath()
+		detectedPaths.prefsFilePath = FindPrefFilePath()
+		detectedPaths.done = true
+	}
+
+	return detectedPaths.spotifyPath, detectedPaths.prefsFilePath
+}
+
+// unknownInstallType marks a config.ini whose spotify_install_type could
+// not be determined on the one-time redetectInstallMetadata pass, so later
+// runs don't keep paying for platform detection (e.g. a spawned `whereis`
+// on Linux) every time with no chance of a different outcome -- darwinApp
+// never sets spotify_install_type at all, so without this every macOS
+// config would otherwise retry on every single invocation.
+const unknownInstallType = "unknown"
+
+// redetectInstallMetadata fills in spotify_launcher/spotify_install_type on
+// a config.ini that predates them. Those keys are only ever set as a side
+// effect of winApp()/linuxApp() and co., which run through
+// detectSpotifyPaths() inside getDefaultConfig() -- so a config.ini that
+// already existed before this commit carries them as permanently blank
+// keys instead of picking up a value on the next run. Re-run detection
+// once to back-fill them, and report whether anything changed.
+func redetectInstallMetadata(cfg *ini.File) bool {
+	section, err := cfg.GetSection("Setting")
+	if err != nil {
+		return false
+	}
+
+	installType, err := section.GetKey("spotify_install_type")
+	if err != nil || len(installType.Value()) != 0 {
+		return false
+	}
+
+	detectSpotifyPaths()
+
+	if value := configLayout["Setting"]["spotify_install_type"]; len(value) != 0 {
+		setSectionValue(section, "spotify_install_type", value)
+		if launcher := configLayout["Setting"]["spotify_launcher"]; len(launcher) != 0 {
+			setSectionValue(section, "spotify_launcher", launcher)
+		}
+		return true
+	}
+
+	setSectionValue(section, "spotify_install_type", unknownInstallType)
+	return true
+}
+
+// setSectionValue sets keyName to value in section, creating the key if it
+// does not exist yet.
+func setSectionValue(section *ini.Section, keyName, value string) {
+	if key, err := section.GetKey(keyName); err == nil {
+		key.SetValue(value)
+		return
+	}
+
+	section.NewKey(keyName, value)
+}
+
 func getDefaultConfig() *ini.File {
 	var cfg = ini.Empty()
 
-	spotifyPath := FindAppPath()
-	prefsFilePath := FindPrefFilePath()
+	spotifyPath, prefsFilePath := detectSpotifyPaths()
 
 	if len(spotifyPath) == 0 {
 		PrintError("Could not detect Spotify location.")
@@ -162,7 +525,7 @@ func FindAppPath() string {
 	case "windows":
 		path := winApp()
 		if len(path) == 0 {
-			PrintInfo("Please make sure you are using normal Spotify version, not Windows Store version.")
+			PrintInfo("Could not find Spotify, including the Microsoft Store version.")
 		}
 
 		return path
@@ -195,9 +558,20 @@ func FindPrefFilePath() string {
 	return ""
 }
 
+// winStoreLauncher is the shell command used to start the Microsoft Store
+// (Appx) build of Spotify, which has no standalone Spotify.exe to exec.
+const winStoreLauncher = `explorer.exe shell:AppsFolder\SpotifyAB.SpotifyMusic_zpdnekdrzrea0!Spotify`
+
 func winApp() string {
 	path := filepath.Join(os.Getenv("APPDATA"), "Spotify")
 	if _, err := os.Stat(path); err == nil {
+		configLayout["Setting"]["spotify_install_type"] = "native"
+		return path
+	}
+
+	if path := winAppStore(); len(path) != 0 {
+		configLayout["Setting"]["spotify_launcher"] = winStoreLauncher
+		configLayout["Setting"]["spotify_install_type"] = "store"
 		return path
 	}
 
@@ -210,9 +584,68 @@ func winPrefs() string {
 		return path
 	}
 
+	if path := winPrefsStore(); len(path) != 0 {
+		return path
+	}
+
+	return ""
+}
+
+// winAppStore finds the app resources of the Microsoft Store (Appx) build
+// of Spotify. It prefers reading the app model registry (see
+// lookupAppxPackage), since %PROGRAMFILES%\WindowsApps itself usually
+// denies directory listing to ordinary, unelevated processes; the glob
+// below is kept as a best-effort fallback for systems where it doesn't.
+func winAppStore() string {
+	if _, path := lookupAppxPackage(); len(path) != 0 {
+		if _, err := os.Stat(filepath.Join(path, "Apps")); err == nil {
+			return path
+		}
+	}
+
+	matches, err := filepath.Glob(filepath.Join(os.Getenv("PROGRAMFILES"), "WindowsApps", "SpotifyAB.SpotifyMusic_*"))
+	if err != nil || len(matches) == 0 {
+		return ""
+	}
+
+	for _, path := range matches {
+		if _, err := os.Stat(filepath.Join(path, "Apps")); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// winPrefsStore finds the "prefs" file of the Microsoft Store (Appx) build
+// of Spotify under %LOCALAPPDATA%\Packages\<package full name>\LocalState\Spotify.
+// It builds the path from the package full name resolved via
+// lookupAppxPackage when available, falling back to a glob otherwise.
+func winPrefsStore() string {
+	if fullName, _ := lookupAppxPackage(); len(fullName) != 0 {
+		prefs := filepath.Join(os.Getenv("LOCALAPPDATA"), "Packages", fullName, "LocalState", "Spotify", "prefs")
+		if _, err := os.Stat(prefs); err == nil {
+			return prefs
+		}
+	}
+
+	matches, err := filepath.Glob(filepath.Join(os.Getenv("LOCALAPPDATA"), "Packages", "SpotifyAB.SpotifyMusic_*", "LocalState", "Spotify", "prefs"))
+	if err != nil {
+		return ""
+	}
+
+	for _, path := range matches {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
 	return ""
 }
 
+// flatpakAppID is the Flatpak application ID Spotify publishes on Flathub.
+const flatpakAppID = "com.spotify.Client"
+
 func linuxApp() string {
 	path, err := exec.Command("whereis", "spotify").Output()
 
@@ -223,19 +656,40 @@ func linuxApp() string {
 
 		for _, v := range pathList {
 			if _, err := os.Stat(filepath.Join(v, "Apps")); err == nil {
+				configLayout["Setting"]["spotify_install_type"] = "native"
 				return v
 			}
 		}
 	}
 
+	if path := linuxAppFlatpak(); len(path) != 0 {
+		configLayout["Setting"]["spotify_install_type"] = "flatpak"
+		return path
+	}
+
 	snap := "/snap/spotify/current/usr/share/spotify"
 	if _, err := os.Stat(snap); err == nil {
+		configLayout["Setting"]["spotify_install_type"] = "snap"
 		return snap
 	}
 
 	return ""
 }
 
+func linuxAppFlatpak() string {
+	userApp := filepath.Join(os.Getenv("HOME"), ".local/share/flatpak/app", flatpakAppID, "current/active/files/extra/share/spotify")
+	if _, err := os.Stat(userApp); err == nil {
+		return userApp
+	}
+
+	systemApp := filepath.Join("/var/lib/flatpak/app", flatpakAppID, "current/active/files/extra/share/spotify")
+	if _, err := os.Stat(systemApp); err == nil {
+		return systemApp
+	}
+
+	return ""
+}
+
 func linuxPrefs() string {
 	// Spotify installed from debian package
 	pref := filepath.Join(os.Getenv("HOME"), ".config/spotify/prefs")
@@ -243,6 +697,12 @@ func linuxPrefs() string {
 		return pref
 	}
 
+	// Spotify installed from Flatpak
+	pref = filepath.Join(os.Getenv("HOME"), ".var/app", flatpakAppID, "config/spotify/prefs")
+	if _, err := os.Stat(pref); err == nil {
+		return pref
+	}
+
 	// Spotify installed from Snap
 	pref = filepath.Join(os.Getenv("HOME"), "snap/spotify/current/.config/spotify/prefs")
 	if _, err := os.Stat(pref); err == nil {
@@ -252,6 +712,30 @@ func linuxPrefs() string {
 	return ""
 }
 
+// flatpakSystemInstallPrefix is the root-owned Flatpak install location
+// shared by every user on the machine, as opposed to the per-user
+// installation under $HOME.
+const flatpakSystemInstallPrefix = "/var/lib/flatpak/"
+
+// FlatpakOverrideCommands returns the commands, if any, the user needs to
+// run for spicetify to be able to patch the Spotify install at appPath. A `flatpak
+// override` only changes what the sandboxed Spotify binary can see at
+// runtime -- it has no effect on what spicetify itself, running outside
+// the sandbox, can read or write, so it does nothing for spicetify's own
+// patching step. A per-user install under ~/.local/share/flatpak is
+// already owned by the invoking user and needs nothing. A system-wide
+// install under /var/lib/flatpak is root-owned, but its files are
+// hardlinked into the shared OSTree object store, so a recursive chown
+// would silently change ownership of objects shared with other refs --
+// the only safe fix is to run the patch step itself as root.
+func FlatpakOverrideCommands(appPath string) []string {
+	if strings.HasPrefix(appPath, flatpakSystemInstallPrefix) {
+		return []string{"sudo spicetify apply"}
+	}
+
+	return nil
+}
+
 func darwinApp() string {
 	path := filepath.Join("/Applications", "Spotify.app", "Contents", "Resources")
 	if _, err := os.Stat(path); err == nil {
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-ini/ini"
+)
+
+func loadFixture(t *testing.T, name string) *ini.File {
+	t.Helper()
+
+	cfg, err := ini.Load(filepath.Join("testdata", "migrations", name))
+	if err != nil {
+		t.Fatalf("failed to load fixture %s: %v", name, err)
+	}
+
+	return cfg
+}
+
+func sectionKeys(cfg *ini.File, name string) map[string]string {
+	result := map[string]string{}
+
+	section, err := cfg.GetSection(name)
+	if err != nil {
+		return result
+	}
+
+	for _, key := range section.Keys() {
+		result[key.Name()] = key.Value()
+	}
+
+	return result
+}
+
+func TestApplyMigrationsV0ToV1MovesPreprocessKeys(t *testing.T) {
+	cfg := loadFixture(t, "legacy_v0.ini")
+
+	if !applyMigrations(cfg) {
+		t.Fatal("expected migration to run against a v0 fixture")
+	}
+
+	golden := loadFixture(t, "legacy_v0.golden.ini")
+
+	if got, want := sectionKeys(cfg, "Setting"), sectionKeys(golden, "Setting"); !reflect.DeepEqual(got, want) {
+		t.Errorf("[Setting] after migration = %v, want %v", got, want)
+	}
+
+	if got, want := sectionKeys(cfg, "Preprocesses"), sectionKeys(golden, "Preprocesses"); !reflect.DeepEqual(got, want) {
+		t.Errorf("[Preprocesses] after migration = %v, want %v", got, want)
+	}
+
+	if version := schemaVersion(cfg); version != currentSchemaVersion {
+		t.Errorf("schema_version = %d, want %d", version, currentSchemaVersion)
+	}
+}
+
+func TestApplyMigrationsAlreadyCurrentIsNoop(t *testing.T) {
+	cfg := loadFixture(t, "current_v1.ini")
+	before := sectionKeys(cfg, "Preprocesses")
+
+	if applyMigrations(cfg) {
+		t.Fatal("expected no migration to run against an already-current fixture")
+	}
+
+	if after := sectionKeys(cfg, "Preprocesses"); !reflect.DeepEqual(before, after) {
+		t.Errorf("[Preprocesses] changed on a no-op migration: before=%v after=%v", before, after)
+	}
+
+	if version := schemaVersion(cfg); version != currentSchemaVersion {
+		t.Errorf("schema_version = %d, want %d", version, currentSchemaVersion)
+	}
+}
+
+func TestParseConfigMigratesAndWritesBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spicetify-config-migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.ini")
+
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "legacy_v0.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(configPath, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ParseConfig(configPath)
+
+	if version := cfg.SchemaVersion(); version != currentSchemaVersion {
+		t.Errorf("SchemaVersion() = %d, want %d", version, currentSchemaVersion)
+	}
+
+	preprocesses := cfg.GetSection("Preprocesses")
+	if key, err := preprocesses.GetKey("expose_apis"); err != nil || key.Value() != "1" {
+		t.Errorf("expose_apis was not migrated into [Preprocesses]: %v", err)
+	}
+
+	backup, err := ioutil.ReadFile(configPath + ".bak")
+	if err != nil {
+		t.Fatalf("expected ParseConfig to leave a .bak of the pre-migration file: %v", err)
+	}
+
+	if string(backup) != string(original) {
+		t.Errorf(".bak contents do not match the pre-migration file")
+	}
+}
+
+func TestParseConfigSkipsBackupWhenAlreadyCurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spicetify-config-migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.ini")
+
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "current_v1.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(configPath, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ParseConfig(configPath)
+
+	if _, err := os.Stat(configPath + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("expected no .bak file when the config is already on the current schema version")
+	}
+}
+
+// withDetectedPaths fakes detectSpotifyPaths() having already run with the
+// given spotify_install_type/spotify_launcher outcome, so
+// redetectInstallMetadata can be tested without shelling out to real OS
+// detection, and restores the package-level state afterwards.
+func withDetectedPaths(t *testing.T, installType, launcher string, fn func()) {
+	t.Helper()
+
+	prevDone := detectedPaths.done
+	prevInstallType := configLayout["Setting"]["spotify_install_type"]
+	prevLauncher := configLayout["Setting"]["spotify_launcher"]
+	defer func() {
+		detectedPaths.done = prevDone
+		configLayout["Setting"]["spotify_install_type"] = prevInstallType
+		configLayout["Setting"]["spotify_launcher"] = prevLauncher
+	}()
+
+	detectedPaths.done = true
+	configLayout["Setting"]["spotify_install_type"] = installType
+	configLayout["Setting"]["spotify_launcher"] = launcher
+
+	fn()
+}
+
+func TestRedetectInstallMetadataBackfillsFromDetection(t *testing.T) {
+	cfg := ini.Empty()
+	section, _ := cfg.NewSection("Setting")
+	section.NewKey("spotify_install_type", "")
+
+	withDetectedPaths(t, "flatpak", "", func() {
+		if !redetectInstallMetadata(cfg) {
+			t.Fatal("expected redetectInstallMetadata to report a change")
+		}
+	})
+
+	key, err := section.GetKey("spotify_install_type")
+	if err != nil || key.Value() != "flatpak" {
+		t.Errorf("spotify_install_type = %v, want flatpak", key)
+	}
+}
+
+func TestRedetectInstallMetadataFallsBackToUnknown(t *testing.T) {
+	cfg := ini.Empty()
+	section, _ := cfg.NewSection("Setting")
+	section.NewKey("spotify_install_type", "")
+
+	withDetectedPaths(t, "", "", func() {
+		if !redetectInstallMetadata(cfg) {
+			t.Fatal("expected redetectInstallMetadata to report a change")
+		}
+	})
+
+	key, err := section.GetKey("spotify_install_type")
+	if err != nil || key.Value() != unknownInstallType {
+		t.Errorf("spotify_install_type = %v, want %s", key, unknownInstallType)
+	}
+}
+
+func TestRedetectInstallMetadataSkipsAlreadySetValue(t *testing.T) {
+	cfg := ini.Empty()
+	section, _ := cfg.NewSection("Setting")
+	section.NewKey("spotify_install_type", "native")
+
+	withDetectedPaths(t, "flatpak", "", func() {
+		if redetectInstallMetadata(cfg) {
+			t.Error("expected no change when spotify_install_type is already set")
+		}
+	})
+
+	key, _ := section.GetKey("spotify_install_type")
+	if key.Value() != "native" {
+		t.Errorf("spotify_install_type changed to %v, want unchanged native", key.Value())
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	valid := []string{"default", "work", "my-profile_2"}
+	invalid := []string{".", "..", "../escape", "a/../../etc", "/etc", `..\escape`}
+
+	for _, profile := range valid {
+		if err := validateProfileName(profile); err != nil {
+			t.Errorf("validateProfileName(%q) = %v, want nil", profile, err)
+		}
+	}
+
+	for _, profile := range invalid {
+		if err := validateProfileName(profile); err == nil {
+			t.Errorf("validateProfileName(%q) = nil, want an error", profile)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package spotifyapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// shutdownGrace is how long waitForCode gives the callback server to flush
+// its response to the browser before tearing it down.
+const shutdownGrace = 2 * time.Second
+
+// waitForCode opens authorizeURL in the user's browser and blocks until
+// Spotify redirects back to the localhost server on port with either a
+// code or an error.
+func waitForCode(ctx context.Context, port int, authorizeURL string) (string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", fmt.Errorf("spotifyapi: could not bind callback server to port %d: %w", port, err)
+	}
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		if errMsg := r.URL.Query().Get("error"); len(errMsg) != 0 {
+			fmt.Fprintln(w, "Authorization failed, you may close this tab.")
+			errCh <- fmt.Errorf("spotifyapi: authorization denied: %s", errMsg)
+			return
+		}
+
+		code := r.URL.Query().Get("code")
+		if len(code) == 0 {
+			fmt.Fprintln(w, "Authorization failed, you may close this tab.")
+			errCh <- fmt.Errorf("spotifyapi: callback missing code")
+			return
+		}
+
+		fmt.Fprintln(w, "Authorization complete, you may close this tab.")
+		codeCh <- code
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := openBrowser(authorizeURL); err != nil {
+		return "", err
+	}
+
+	select {
+	case code := <-codeCh:
+		return code, nil
+	case err := <-errCh:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func openBrowser(target string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", target).Start()
+	case "darwin":
+		return exec.Command("open", target).Start()
+	default:
+		return exec.Command("xdg-open", target).Start()
+	}
+}
@@ -0,0 +1,63 @@
+package spotifyapi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// codeVerifierLength lies within the 43-128 char range the PKCE spec
+// (RFC 7636) allows.
+const codeVerifierLength = 64
+
+const verifierAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+
+// maxUnbiasedByte is the largest multiple of len(verifierAlphabet) that
+// does not exceed 256; bytes at or above it are resampled instead of taken
+// mod len(verifierAlphabet), so every character stays equally likely.
+const maxUnbiasedByte = 256 - 256%len(verifierAlphabet)
+
+// mapVerifierByte maps a random byte to a verifierAlphabet character,
+// rejecting b if it would introduce modulo bias. ok is false when b should
+// be discarded and another byte read in its place.
+func mapVerifierByte(b byte) (c byte, ok bool) {
+	if int(b) >= maxUnbiasedByte {
+		return 0, false
+	}
+
+	return verifierAlphabet[int(b)%len(verifierAlphabet)], true
+}
+
+// generateCodeVerifier returns a CSPRNG-backed code_verifier for the
+// PKCE Authorization Code flow.
+func generateCodeVerifier() (string, error) {
+	verifier := make([]byte, 0, codeVerifierLength)
+	buf := make([]byte, codeVerifierLength)
+
+	for len(verifier) < codeVerifierLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			c, ok := mapVerifierByte(b)
+			if !ok {
+				continue
+			}
+
+			verifier = append(verifier, c)
+			if len(verifier) == codeVerifierLength {
+				break
+			}
+		}
+	}
+
+	return string(verifier), nil
+}
+
+// codeChallengeS256 derives the code_challenge sent in the authorize
+// request from verifier, using the S256 method.
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
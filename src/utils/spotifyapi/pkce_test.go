@@ -0,0 +1,60 @@
+package spotifyapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateCodeVerifierLengthAndCharset(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		verifier, err := generateCodeVerifier()
+		if err != nil {
+			t.Fatalf("generateCodeVerifier() returned error: %v", err)
+		}
+
+		if len(verifier) != codeVerifierLength {
+			t.Fatalf("len(verifier) = %d, want %d", len(verifier), codeVerifierLength)
+		}
+
+		for _, r := range verifier {
+			if !strings.ContainsRune(verifierAlphabet, r) {
+				t.Fatalf("verifier contains %q, which is not in verifierAlphabet", r)
+			}
+		}
+	}
+}
+
+func TestMapVerifierByteRejectsBiasedBytes(t *testing.T) {
+	// 256 is not an exact multiple of len(verifierAlphabet), so every byte
+	// from maxUnbiasedByte up to 255 must be rejected rather than taken
+	// mod len(verifierAlphabet) -- this is the modulo bias bdc5959 fixed.
+	for b := 0; b <= 255; b++ {
+		c, ok := mapVerifierByte(byte(b))
+
+		if b >= maxUnbiasedByte {
+			if ok {
+				t.Errorf("mapVerifierByte(%d) = (%q, true), want ok=false", b, c)
+			}
+			continue
+		}
+
+		if !ok {
+			t.Errorf("mapVerifierByte(%d) = (_, false), want ok=true", b)
+			continue
+		}
+
+		if !strings.ContainsRune(verifierAlphabet, rune(c)) {
+			t.Errorf("mapVerifierByte(%d) = %q, not in verifierAlphabet", b, c)
+		}
+	}
+}
+
+func TestCodeChallengeS256KnownVector(t *testing.T) {
+	// RFC 7636 Appendix B.
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const want = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := codeChallengeS256(verifier); got != want {
+		t.Errorf("codeChallengeS256(%q) = %q, want %q", verifier, got, want)
+	}
+}
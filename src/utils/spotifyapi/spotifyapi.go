@@ -0,0 +1,270 @@
+// Package spotifyapi implements the Spotify Web API's OAuth 2.0
+// Authorization Code + PKCE flow and a small read-only REST surface (the
+// current user's profile and playlists) for spicetify's account-aware
+// features. Playback state and playlist writes are not covered.
+package spotifyapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	authURL  = "https://accounts.spotify.com/authorize"
+	tokenURL = "https://accounts.spotify.com/api/token"
+	apiBase  = "https://api.spotify.com/v1"
+)
+
+// User is the subset of Spotify's "current user" object spicetify needs.
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+// Playlist is the subset of Spotify's simplified playlist object that
+// UserPlaylists decodes; it carries no track list.
+type Playlist struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Public bool   `json:"public"`
+}
+
+// SpotifyAPI is the account-aware surface spicetify features are built on.
+// It hides the OAuth/PKCE dance and transparent token refresh behind plain
+// method calls.
+type SpotifyAPI interface {
+	CurrentUser(ctx context.Context) (*User, error)
+	UserPlaylists(ctx context.Context) ([]Playlist, error)
+}
+
+// Config is what the client needs from spicetify's [Account] settings.
+type Config struct {
+	ClientID     string
+	RedirectPort int
+	Scopes       []string
+	// ConfigDir is the directory returned by utils.Config.GetPath's
+	// directory, used to cache the refresh token alongside config.ini.
+	ConfigDir string
+}
+
+// Client is a SpotifyAPI backed by a cached, auto-refreshing OAuth token.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+	tok        *token
+}
+
+// NewClient loads a cached token for cfg, running the PKCE authorization
+// flow through a localhost callback server on cfg.RedirectPort if none is
+// cached yet or the cached refresh token has been revoked.
+func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+	c := &Client{cfg: cfg, httpClient: http.DefaultClient}
+
+	if t, err := loadToken(cfg.ConfigDir); err == nil {
+		c.tok = t
+	} else {
+		t, err := c.authorize(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.tok = t
+	}
+
+	return c, nil
+}
+
+// authorize runs the full Authorization Code + PKCE flow: it generates a
+// code_verifier/code_challenge pair, opens the user's browser to Spotify's
+// consent screen, receives the redirect on a localhost server, and
+// exchanges the code for a token.
+func (c *Client) authorize(ctx context.Context) (*token, error) {
+	verifier, err := generateCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+	challenge := codeChallengeS256(verifier)
+
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", c.cfg.RedirectPort)
+
+	code, err := waitForCode(ctx, c.cfg.RedirectPort, authorizeURL(c.cfg, redirectURI, challenge))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.exchangeCode(ctx, code, verifier, redirectURI)
+}
+
+func authorizeURL(cfg Config, redirectURI, challenge string) string {
+	q := url.Values{}
+	q.Set("client_id", cfg.ClientID)
+	q.Set("response_type", "code")
+	q.Set("redirect_uri", redirectURI)
+	q.Set("code_challenge_method", "S256")
+	q.Set("code_challenge", challenge)
+	q.Set("scope", strings.Join(cfg.Scopes, " "))
+
+	return authURL + "?" + q.Encode()
+}
+
+func (c *Client) exchangeCode(ctx context.Context, code, verifier, redirectURI string) (*token, error) {
+	form := url.Values{}
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+
+	return c.requestToken(ctx, form)
+}
+
+// errInvalidGrant is returned by requestToken when Spotify's token endpoint
+// reports its "invalid_grant" OAuth error, meaning the authorization code
+// or refresh token passed in the request was itself rejected (expired,
+// already used, or revoked) rather than some transient failure.
+var errInvalidGrant = errors.New("spotifyapi: invalid_grant")
+
+func (c *Client) refresh(ctx context.Context) error {
+	form := url.Values{}
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", c.tok.RefreshToken)
+
+	t, err := c.requestToken(ctx, form)
+	if err != nil {
+		if errors.Is(err, errInvalidGrant) {
+			// The refresh token itself was revoked and can never succeed
+			// again; drop the cache so the next GetAPIClient call runs a
+			// fresh interactive authorization instead of NewClient handing
+			// back a client backed by the same dead token forever.
+			_ = removeToken(c.cfg.ConfigDir)
+		}
+
+		return err
+	}
+
+	if len(t.RefreshToken) == 0 {
+		// Spotify may omit refresh_token when it hasn't rotated.
+		t.RefreshToken = c.tok.RefreshToken
+	}
+
+	c.tok = t
+	return saveToken(c.cfg.ConfigDir, c.tok)
+}
+
+func (c *Client) requestToken(ctx context.Context, form url.Values) (*token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&oauthErr)
+
+		if oauthErr.Error == "invalid_grant" {
+			return nil, fmt.Errorf("%w: status %s", errInvalidGrant, resp.Status)
+		}
+
+		return nil, fmt.Errorf("spotifyapi: token request failed with status %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	t := &token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}
+
+	if err := saveToken(c.cfg.ConfigDir, t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ensureFreshToken refreshes c.tok if it is within refreshSkew of expiring.
+func (c *Client) ensureFreshToken(ctx context.Context) error {
+	if c.tok.expired() {
+		return c.refresh(ctx)
+	}
+
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	if err := c.ensureFreshToken(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiBase+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.tok.AccessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("spotifyapi: %s failed with status %s", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CurrentUser returns the authenticated user's profile.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var u User
+	if err := c.get(ctx, "/me", &u); err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// UserPlaylists returns the authenticated user's playlists.
+func (c *Client) UserPlaylists(ctx context.Context) ([]Playlist, error) {
+	var playlists []Playlist
+	for offset := 0; ; offset += 50 {
+		var page struct {
+			Items []Playlist `json:"items"`
+			Next  string     `json:"next"`
+		}
+		if err := c.get(ctx, fmt.Sprintf("/me/playlists?limit=50&offset=%d", offset), &page); err != nil {
+			return nil, err
+		}
+
+		playlists = append(playlists, page.Items...)
+		if len(page.Next) == 0 || len(page.Items) == 0 {
+			return playlists, nil
+		}
+	}
+}
+
+var _ SpotifyAPI = (*Client)(nil)
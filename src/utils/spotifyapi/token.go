@@ -0,0 +1,65 @@
+package spotifyapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tokenFileName is written in the directory of the path returned by
+// Config.GetPath, next to config.ini.
+const tokenFileName = "spotifyapi_token.json"
+
+// token is the cached OAuth state for one account, refreshed transparently
+// whenever it is within refreshSkew of expiring.
+type token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// refreshSkew is how far ahead of the real expiry a token is treated as
+// already expired, so a request started just before expiry doesn't race it.
+const refreshSkew = 60 * time.Second
+
+func (t token) expired() bool {
+	return time.Now().Add(refreshSkew).After(t.ExpiresAt)
+}
+
+func tokenPath(configDir string) string {
+	return filepath.Join(configDir, tokenFileName)
+}
+
+func loadToken(configDir string) (*token, error) {
+	content, err := ioutil.ReadFile(tokenPath(configDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var t token
+	if err := json.Unmarshal(content, &t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func saveToken(configDir string, t *token) error {
+	content, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(tokenPath(configDir), content, 0600)
+}
+
+func removeToken(configDir string) error {
+	err := os.Remove(tokenPath(configDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}